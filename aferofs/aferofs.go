@@ -0,0 +1,204 @@
+// Package aferofs adapts a gar-embedded io/fs.FS into an afero.Fs, and
+// layers a writable afero.Fs on top of it so operators can override
+// individual embedded files at runtime (e.g. /etc/myapp/ taking
+// precedence over files baked into the binary). It is a separate package
+// so that depending on gar.FS or gar.NewFileSystem never pulls in afero.
+package aferofs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/afero"
+
+	"github.com/zncoder/gar"
+)
+
+// New adapts ro into a read-only afero.Fs, so an embedded gar archive
+// composes with afero helpers like afero.NewHttpFs, afero.NewBasePathFs,
+// and afero.NewCopyOnWriteFs.
+func New(ro fs.FS) afero.Fs {
+	return &readOnlyFs{ro: ro}
+}
+
+// NewOverlay returns a copy-on-write afero.Fs whose reads fall through to
+// the program's embedded gar archive (gar.FS()) and whose writes land in
+// base.
+func NewOverlay(base afero.Fs) afero.Fs {
+	return afero.NewCopyOnWriteFs(New(gar.FS()), base)
+}
+
+func clean(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+type readOnlyFs struct{ ro fs.FS }
+
+func (r *readOnlyFs) Name() string { return "garfs" }
+
+func (r *readOnlyFs) Open(name string) (afero.File, error) {
+	f, err := r.ro.Open(clean(name))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.IsDir() {
+		f.Close()
+		entries, err := fs.ReadDir(r.ro, clean(name))
+		if err != nil {
+			return nil, err
+		}
+		return &dirFile{name: name, fi: fi, entries: entries}, nil
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &file{name: name, fi: fi, r: bytes.NewReader(data)}, nil
+}
+
+func (r *readOnlyFs) OpenFile(name string, flag int, perm os.FileMode) (afero.File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EPERM}
+	}
+	return r.Open(name)
+}
+
+func (r *readOnlyFs) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(r.ro, clean(name))
+}
+
+func (r *readOnlyFs) Create(name string) (afero.File, error) {
+	return nil, &os.PathError{Op: "create", Path: name, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Mkdir(name string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) MkdirAll(path string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: path, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) RemoveAll(path string) error {
+	return &os.PathError{Op: "remove", Path: path, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Rename(oldname, newname string) error {
+	return &os.PathError{Op: "rename", Path: oldname, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Chmod(name string, mode os.FileMode) error {
+	return &os.PathError{Op: "chmod", Path: name, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Chtimes(name string, atime, mtime time.Time) error {
+	return &os.PathError{Op: "chtimes", Path: name, Err: syscall.EPERM}
+}
+
+func (r *readOnlyFs) Chown(name string, uid, gid int) error {
+	return &os.PathError{Op: "chown", Path: name, Err: syscall.EPERM}
+}
+
+// file is a read-only afero.File backed by the fully-read contents of an
+// embedded entry, since *zip.File.Open only returns an io.ReadCloser and
+// afero.File needs seekable reads.
+type file struct {
+	name string
+	fi   fs.FileInfo
+	r    *bytes.Reader
+}
+
+func (f *file) Close() error                                 { return nil }
+func (f *file) Read(p []byte) (int, error)                   { return f.r.Read(p) }
+func (f *file) ReadAt(p []byte, off int64) (int, error)      { return f.r.ReadAt(p, off) }
+func (f *file) Seek(offset int64, whence int) (int64, error) { return f.r.Seek(offset, whence) }
+func (f *file) Write(p []byte) (int, error)                  { return 0, syscall.EPERM }
+func (f *file) WriteAt(p []byte, off int64) (int, error)     { return 0, syscall.EPERM }
+func (f *file) Name() string                                 { return f.name }
+func (f *file) Readdir(count int) ([]os.FileInfo, error)     { return nil, syscall.ENOTDIR }
+func (f *file) Readdirnames(n int) ([]string, error)         { return nil, syscall.ENOTDIR }
+func (f *file) Stat() (os.FileInfo, error)                   { return f.fi, nil }
+func (f *file) Sync() error                                  { return nil }
+func (f *file) Truncate(size int64) error                    { return syscall.EPERM }
+func (f *file) WriteString(s string) (ret int, err error)    { return 0, syscall.EPERM }
+
+// dirFile is a read-only afero.File for a directory, listing the
+// fs.DirEntry values io/fs already gathered for us.
+type dirFile struct {
+	name    string
+	fi      fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Close() error                       { return nil }
+func (d *dirFile) Read([]byte) (int, error)           { return 0, syscall.EISDIR }
+func (d *dirFile) ReadAt([]byte, int64) (int, error)  { return 0, syscall.EISDIR }
+func (d *dirFile) Seek(int64, int) (int64, error)     { return 0, syscall.EISDIR }
+func (d *dirFile) Write([]byte) (int, error)          { return 0, syscall.EPERM }
+func (d *dirFile) WriteAt([]byte, int64) (int, error) { return 0, syscall.EPERM }
+func (d *dirFile) Name() string                       { return d.name }
+func (d *dirFile) Stat() (os.FileInfo, error)         { return d.fi, nil }
+func (d *dirFile) Sync() error                        { return nil }
+func (d *dirFile) Truncate(int64) error               { return syscall.EPERM }
+func (d *dirFile) WriteString(string) (int, error)    { return 0, syscall.EPERM }
+
+func (d *dirFile) Readdir(count int) ([]os.FileInfo, error) {
+	if count <= 0 {
+		infos := make([]os.FileInfo, 0, len(d.entries)-d.offset)
+		for _, e := range d.entries[d.offset:] {
+			fi, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, fi)
+		}
+		d.offset = len(d.entries)
+		return infos, nil
+	}
+	if d.offset >= len(d.entries) {
+		return nil, io.EOF
+	}
+	end := d.offset + count
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	infos := make([]os.FileInfo, 0, end-d.offset)
+	for _, e := range d.entries[d.offset:end] {
+		fi, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, fi)
+	}
+	d.offset = end
+	return infos, nil
+}
+
+func (d *dirFile) Readdirnames(n int) ([]string, error) {
+	infos, err := d.Readdir(n)
+	names := make([]string, len(infos))
+	for i, fi := range infos {
+		names[i] = fi.Name()
+	}
+	return names, err
+}
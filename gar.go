@@ -1,29 +1,456 @@
 package gar
 
 import (
+	"archive/tar"
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/gob"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	suffix       = "GAR"  // unsigned trailer magic
+	signedSuffix = "GARS" // signed trailer magic
+
+	// unsignedTrailerLen is [offset(8) | format(1) | "GAR"].
+	unsignedTrailerLen = int64(8 + 1 + len(suffix))
+	// legacyTrailerLen is the original [offset(8) | "GAR"] trailer, from
+	// before archives carried a format byte; always zip.
+	legacyTrailerLen = int64(8 + len(suffix))
+	// signedTrailerLen is [sig(64) | hash(32) | pubkey(32) | offset(8) |
+	// format(1) | "GARS"].
+	signedTrailerLen = int64(ed25519.SignatureSize + sha256.Size + ed25519.PublicKeySize + 8 + 1 + len(signedSuffix))
+)
+
+// Container format bytes recorded in the trailer.
+const (
+	formatZip     byte = 0
+	formatTarZstd byte = 1
 )
 
-const suffix = "GAR"
+// deterministicModTime is stamped on every entry when
+// ArchiverOptions.Deterministic is set, so that archiving the same inputs
+// always produces byte-identical output.
+var deterministicModTime = time.Unix(0, 0)
+
+// FormatHeader is the format-agnostic metadata for one archived entry.
+type FormatHeader struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+// FormatWriter streams entries into a container written by a Format.
+type FormatWriter interface {
+	CreateHeader(hdr FormatHeader) (io.Writer, error)
+	Close() error
+}
+
+// FormatReader gives random access into a container written by a Format.
+type FormatReader interface {
+	Files() []FormatHeader
+	Open(name string) (io.ReadCloser, error)
+}
+
+// Format is a pluggable container for the payload gar appends to a
+// binary. ZipFormat (the default, and the only format earlier versions of
+// gar ever wrote) and TarZstdFormat both implement it.
+type Format interface {
+	NewWriter(w io.Writer) (FormatWriter, error)
+	NewReader(r io.ReaderAt, size int64) (FormatReader, error)
+}
+
+func formatByte(f Format) byte {
+	if _, ok := f.(TarZstdFormat); ok {
+		return formatTarZstd
+	}
+	return formatZip
+}
+
+func formatFromByte(b byte) (Format, error) {
+	switch b {
+	case formatZip:
+		return ZipFormat{}, nil
+	case formatTarZstd:
+		return TarZstdFormat{}, nil
+	default:
+		return nil, fmt.Errorf("unknown gar container format byte %d", b)
+	}
+}
+
+// ZipFormat is the default Format, backed by archive/zip with the same
+// on-disk layout gar has always used.
+type ZipFormat struct {
+	// Method is the zip compression method, e.g. zip.Deflate. The zero
+	// value behaves like zip.Deflate; since zip.Store is that zero value,
+	// entries are never written stored (uncompressed).
+	Method uint16
+}
+
+type zipFormatWriter struct {
+	zw     *zip.Writer
+	method uint16
+}
+
+func (z ZipFormat) NewWriter(w io.Writer) (FormatWriter, error) {
+	method := z.Method
+	if method == zip.Store {
+		method = zip.Deflate
+	}
+	return &zipFormatWriter{zw: zip.NewWriter(w), method: method}, nil
+}
+
+func (z ZipFormat) NewReader(r io.ReaderAt, size int64) (FormatReader, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, zf := range zr.File {
+		files[zf.Name] = zf
+	}
+	return &zipFormatReader{zr: zr, files: files}, nil
+}
+
+func (zw *zipFormatWriter) CreateHeader(h FormatHeader) (io.Writer, error) {
+	hdr := &zip.FileHeader{Name: h.Name, Method: zw.method}
+	hdr.SetMode(h.Mode)
+	hdr.Modified = h.ModTime
+	return zw.zw.CreateHeader(hdr)
+}
+
+func (zw *zipFormatWriter) Close() error { return zw.zw.Close() }
+
+type zipFormatReader struct {
+	zr    *zip.Reader
+	files map[string]*zip.File
+}
+
+func (zr *zipFormatReader) Files() []FormatHeader {
+	hdrs := make([]FormatHeader, len(zr.zr.File))
+	for i, zf := range zr.zr.File {
+		hdrs[i] = FormatHeader{
+			Name:    zf.Name,
+			Size:    int64(zf.UncompressedSize64),
+			Mode:    zf.Mode(),
+			ModTime: zf.Modified,
+		}
+	}
+	return hdrs
+}
+
+func (zr *zipFormatReader) Open(name string) (io.ReadCloser, error) {
+	zf, ok := zr.files[name]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", name)
+	}
+	return zf.Open()
+}
+
+// TarZstdFormat stores each entry as an independently-decodable tar
+// header+body, zstd-compressed as its own frame, with a gob-encoded index
+// (name -> offset/length/uncompressed size) appended at the tail for
+// random access. Dict, if set, is a shared zstd dictionary, which can
+// shrink archives of many similar small files (JSON, HTML templates, ...)
+// dramatically since every frame can reference it despite being
+// independently seekable.
+type TarZstdFormat struct {
+	Dict []byte
+}
+
+type tarZstdIndexEntry struct {
+	Name             string
+	Offset           int64
+	Length           int64
+	UncompressedSize int64
+	Mode             fs.FileMode
+	ModTime          time.Time
+}
+
+type tarZstdWriter struct {
+	w       io.Writer
+	dict    []byte
+	offset  int64
+	index   []tarZstdIndexEntry
+	pending *tarZstdEntryWriter
+}
+
+func (f TarZstdFormat) NewWriter(w io.Writer) (FormatWriter, error) {
+	return &tarZstdWriter{w: w, dict: f.Dict}, nil
+}
+
+type tarZstdEntryWriter struct {
+	parent  *tarZstdWriter
+	hdr     FormatHeader
+	buf     bytes.Buffer
+	written int64
+}
+
+func (tw *tarZstdWriter) CreateHeader(h FormatHeader) (io.Writer, error) {
+	if tw.pending != nil {
+		if err := tw.pending.finish(); err != nil {
+			return nil, err
+		}
+		tw.pending = nil
+	}
+	ew := &tarZstdEntryWriter{parent: tw, hdr: h}
+	if h.Size == 0 {
+		if err := ew.finish(); err != nil {
+			return nil, err
+		}
+		return ew, nil
+	}
+	tw.pending = ew
+	return ew, nil
+}
+
+func (ew *tarZstdEntryWriter) Write(p []byte) (int, error) {
+	if ew.parent.pending != ew {
+		return 0, fmt.Errorf("write to %s after its declared size %d was already reached", ew.hdr.Name, ew.hdr.Size)
+	}
+	n, err := ew.buf.Write(p)
+	ew.written += int64(n)
+	if err == nil && ew.written >= ew.hdr.Size && ew.parent.pending == ew {
+		if ferr := ew.finish(); ferr != nil {
+			return n, ferr
+		}
+		ew.parent.pending = nil
+	}
+	return n, err
+}
+
+func (ew *tarZstdEntryWriter) finish() error {
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+	thdr := &tar.Header{
+		Name:     ew.hdr.Name,
+		Size:     ew.hdr.Size,
+		Mode:     int64(ew.hdr.Mode.Perm()),
+		ModTime:  ew.hdr.ModTime,
+		Typeflag: tar.TypeReg,
+	}
+	if err := tw.WriteHeader(thdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(ew.buf.Bytes()); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	var zbuf bytes.Buffer
+	zopts := zstdEncoderOpts(ew.parent.dict)
+	zw, err := zstd.NewWriter(&zbuf, zopts...)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(tarBuf.Bytes()); err != nil {
+		zw.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	n, err := ew.parent.w.Write(zbuf.Bytes())
+	if err != nil {
+		return err
+	}
+	ew.parent.index = append(ew.parent.index, tarZstdIndexEntry{
+		Name:             ew.hdr.Name,
+		Offset:           ew.parent.offset,
+		Length:           int64(n),
+		UncompressedSize: ew.hdr.Size,
+		Mode:             ew.hdr.Mode,
+		ModTime:          ew.hdr.ModTime,
+	})
+	ew.parent.offset += int64(n)
+	return nil
+}
+
+func (tw *tarZstdWriter) Close() error {
+	if tw.pending != nil {
+		if err := tw.pending.finish(); err != nil {
+			return err
+		}
+		tw.pending = nil
+	}
+	var idxBuf bytes.Buffer
+	if err := gob.NewEncoder(&idxBuf).Encode(tw.index); err != nil {
+		return err
+	}
+	if _, err := tw.w.Write(idxBuf.Bytes()); err != nil {
+		return err
+	}
+	lenB := make([]byte, 4)
+	binary.BigEndian.PutUint32(lenB, uint32(idxBuf.Len()))
+	_, err := tw.w.Write(lenB)
+	return err
+}
+
+func zstdEncoderOpts(dict []byte) []zstd.EOption {
+	if len(dict) == 0 {
+		return nil
+	}
+	return []zstd.EOption{zstd.WithEncoderDict(dict)}
+}
+
+func zstdDecoderOpts(dict []byte) []zstd.DOption {
+	if len(dict) == 0 {
+		return nil
+	}
+	return []zstd.DOption{zstd.WithDecoderDicts(dict)}
+}
+
+type tarZstdReader struct {
+	ra    io.ReaderAt
+	dict  []byte
+	index []tarZstdIndexEntry
+}
+
+func (f TarZstdFormat) NewReader(r io.ReaderAt, size int64) (FormatReader, error) {
+	if size < 4 {
+		return nil, fmt.Errorf("tar+zstd region too short")
+	}
+	lenB := make([]byte, 4)
+	if _, err := r.ReadAt(lenB, size-4); err != nil {
+		return nil, err
+	}
+	idxLen := int64(binary.BigEndian.Uint32(lenB))
+	if idxLen < 0 || idxLen > size-4 {
+		return nil, fmt.Errorf("invalid tar+zstd index length %d", idxLen)
+	}
+	idxBuf := make([]byte, idxLen)
+	if _, err := r.ReadAt(idxBuf, size-4-idxLen); err != nil {
+		return nil, err
+	}
+	var index []tarZstdIndexEntry
+	if err := gob.NewDecoder(bytes.NewReader(idxBuf)).Decode(&index); err != nil {
+		return nil, err
+	}
+	return &tarZstdReader{ra: r, dict: f.Dict, index: index}, nil
+}
+
+func (tr *tarZstdReader) Files() []FormatHeader {
+	hdrs := make([]FormatHeader, len(tr.index))
+	for i, e := range tr.index {
+		hdrs[i] = FormatHeader{Name: e.Name, Size: e.UncompressedSize, Mode: e.Mode, ModTime: e.ModTime}
+	}
+	return hdrs
+}
+
+func (tr *tarZstdReader) Open(name string) (io.ReadCloser, error) {
+	for _, e := range tr.index {
+		if e.Name != name {
+			continue
+		}
+		zr, err := zstd.NewReader(io.NewSectionReader(tr.ra, e.Offset, e.Length), zstdDecoderOpts(tr.dict)...)
+		if err != nil {
+			return nil, err
+		}
+		t := tar.NewReader(zr)
+		if _, err := t.Next(); err != nil {
+			zr.Close()
+			return nil, err
+		}
+		return &tarZstdEntryReader{tr: t, zr: zr}, nil
+	}
+	return nil, fmt.Errorf("file %s not found", name)
+}
+
+type tarZstdEntryReader struct {
+	tr *tar.Reader
+	zr *zstd.Decoder
+}
+
+func (r *tarZstdEntryReader) Read(p []byte) (int, error) { return r.tr.Read(p) }
+
+func (r *tarZstdEntryReader) Close() error {
+	r.zr.Close()
+	return nil
+}
+
+// ArchiverOptions configures how Archiver.Add and Archiver.AddTree record
+// entries.
+type ArchiverOptions struct {
+	// Deterministic forces every entry's mtime to a fixed value instead of
+	// the source file's mtime, so repeated archiving of the same inputs is
+	// reproducible.
+	Deterministic bool
+
+	// Method is the zip compression method, e.g. zip.Deflate.
+	// Only used when Format is ZipFormat (the default). The zero value
+	// behaves like zip.Deflate; since zip.Store is that zero value,
+	// entries are never written stored (uncompressed).
+	Method uint16
+
+	// Format selects the container for the archive payload. The zero
+	// value uses ZipFormat{Method: Method}.
+	Format Format
+
+	// ZstdDict is a shared zstd dictionary applied when Format is
+	// TarZstdFormat and doesn't already carry its own Dict.
+	ZstdDict []byte
+
+	// SignKey, if set, causes Close to hash the payload region with
+	// SHA-256 and append a signed trailer carrying that hash, its Ed25519
+	// signature, and the corresponding public key, so
+	// NewFileSystemWithOptions can detect tampering.
+	SignKey ed25519.PrivateKey
+}
+
+func (opts ArchiverOptions) format() Format {
+	f := opts.Format
+	if f == nil {
+		f = ZipFormat{Method: opts.Method}
+	}
+	if tz, ok := f.(TarZstdFormat); ok && tz.Dict == nil && opts.ZstdDict != nil {
+		tz.Dict = opts.ZstdDict
+		f = tz
+	}
+	return f
+}
 
 type Archiver struct {
 	f        *os.File
 	fw       *bufio.Writer
 	fileSize int64
-	zw       *zip.Writer
+	cw       FormatWriter
+	format   Format
+	hasher   hashWriter
+	opts     ArchiverOptions
 	err      error
 }
 
+// hashWriter is satisfied by hash.Hash; named narrowly so Archiver doesn't
+// need to import "hash" just for the field type.
+type hashWriter interface {
+	io.Writer
+	Sum(b []byte) []byte
+}
+
 func NewArchiver(fn string) (*Archiver, error) {
+	return NewArchiverWithOptions(fn, ArchiverOptions{})
+}
+
+func NewArchiverWithOptions(fn string, opts ArchiverOptions) (*Archiver, error) {
 	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND, 0755)
 	if err != nil {
 		return nil, err
@@ -34,11 +461,21 @@ func NewArchiver(fn string) (*Archiver, error) {
 		f.Close()
 		return nil, err
 	}
+	hasher := sha256.New()
+	format := opts.format()
+	cw, err := format.NewWriter(io.MultiWriter(fw, hasher))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
 	return &Archiver{
 		f:        f,
 		fw:       fw,
 		fileSize: fi.Size(),
-		zw:       zip.NewWriter(fw),
+		cw:       cw,
+		format:   format,
+		hasher:   hasher,
+		opts:     opts,
 	}, nil
 }
 
@@ -53,18 +490,129 @@ func (ar *Archiver) Add(fn string) error {
 	}
 	defer in.Close()
 
-	fn = filepath.Clean(fn)
-	fn = filepath.ToSlash(fn)
-	fn = strings.TrimLeft(fn, "/") // make it relative to cwd
+	fi, err := in.Stat()
+	if ar.setErr(err) != nil {
+		return ar.err
+	}
+
+	name := filepath.ToSlash(strings.TrimLeft(filepath.Clean(fn), "/")) // make it relative to cwd
+	return ar.addEntry(name, fi, in)
+}
 
-	out, err := ar.zw.Create(fn)
+// addEntry writes a single entry for name, carrying over fi's mode and
+// mtime (subject to ArchiverOptions.Deterministic), with contents read
+// from r.
+func (ar *Archiver) addEntry(name string, fi os.FileInfo, r io.Reader) error {
+	modTime := fi.ModTime()
+	if ar.opts.Deterministic {
+		modTime = deterministicModTime
+	}
+	out, err := ar.cw.CreateHeader(FormatHeader{
+		Name:    name,
+		Size:    fi.Size(),
+		Mode:    fi.Mode(),
+		ModTime: modTime,
+	})
 	if ar.setErr(err) != nil {
 		return ar.err
 	}
-	_, err = io.Copy(out, in)
+	_, err = io.Copy(out, r)
 	return ar.setErr(err)
 }
 
+// TreeOptions configures Archiver.AddTree.
+type TreeOptions struct {
+	// Include, if non-empty, keeps only entries whose archive-relative
+	// slash path matches one of these path.Match patterns.
+	Include []string
+
+	// Exclude drops entries whose archive-relative slash path matches one
+	// of these path.Match patterns, after Include is applied.
+	Exclude []string
+
+	// FollowSymlinks archives the symlink target's contents instead of
+	// recording the symlink itself.
+	FollowSymlinks bool
+}
+
+// AddTree walks root and adds every matching regular file (and, depending
+// on opts.FollowSymlinks, every symlink) as an entry rooted at root, with
+// paths normalized to slash form. The archive output itself is skipped so
+// gar -a -R doesn't recursively embed the binary being built.
+func (ar *Archiver) AddTree(root string, opts TreeOptions) error {
+	if ar.err != nil {
+		return ar.err
+	}
+
+	binPath, err := filepath.Abs(ar.f.Name())
+	if ar.setErr(err) != nil {
+		return ar.err
+	}
+
+	err = filepath.WalkDir(root, func(fn string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if absFn, aerr := filepath.Abs(fn); aerr == nil && absFn == binPath {
+			return nil
+		}
+
+		name := filepath.ToSlash(fn)
+		if !matchTree(name, opts.Include, opts.Exclude) {
+			return nil
+		}
+
+		if d.Type()&fs.ModeSymlink != 0 && !opts.FollowSymlinks {
+			target, err := os.Readlink(fn)
+			if err != nil {
+				return err
+			}
+			fi, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return ar.addEntry(name, fi, strings.NewReader(target))
+		}
+
+		in, err := os.Open(fn)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+
+		fi, err := in.Stat()
+		if err != nil {
+			return err
+		}
+		return ar.addEntry(name, fi, in)
+	})
+	return ar.setErr(err)
+}
+
+func matchTree(name string, include, exclude []string) bool {
+	if len(include) > 0 {
+		ok := false
+		for _, pat := range include {
+			if m, _ := path.Match(pat, name); m {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	for _, pat := range exclude {
+		if m, _ := path.Match(pat, name); m {
+			return false
+		}
+	}
+	return true
+}
+
 func (ar *Archiver) setErr(err error) error {
 	if ar.err == nil {
 		ar.err = err
@@ -72,15 +620,48 @@ func (ar *Archiver) setErr(err error) error {
 	return ar.err
 }
 
+// signedTrailer builds [sig(64) | hash(32) | pubkey(32) | offset(8) |
+// format(1) | "GARS"], signing the SHA-256 of the payload region that was
+// streamed through ar.hasher as it was written.
+func (ar *Archiver) signedTrailer() []byte {
+	sum := ar.hasher.Sum(nil)
+	pub := ar.opts.SignKey.Public().(ed25519.PublicKey)
+	sig := ed25519.Sign(ar.opts.SignKey, sum)
+
+	b := make([]byte, 0, signedTrailerLen)
+	b = append(b, sig...)
+	b = append(b, sum...)
+	b = append(b, pub...)
+	offset := make([]byte, 8)
+	binary.BigEndian.PutUint64(offset, uint64(ar.fileSize))
+	b = append(b, offset...)
+	b = append(b, formatByte(ar.format))
+	b = append(b, signedSuffix...)
+	return b
+}
+
+func (ar *Archiver) unsignedTrailer() []byte {
+	b := make([]byte, 0, unsignedTrailerLen)
+	offset := make([]byte, 8)
+	binary.BigEndian.PutUint64(offset, uint64(ar.fileSize))
+	b = append(b, offset...)
+	b = append(b, formatByte(ar.format))
+	b = append(b, suffix...)
+	return b
+}
+
 func (ar *Archiver) Close() error {
 	if ar.f == nil {
 		return ar.err
 	}
-	ar.setErr(ar.zw.Close())
+	ar.setErr(ar.cw.Close())
 	if ar.err == nil {
-		b := make([]byte, 8+len(suffix))
-		binary.BigEndian.PutUint64(b, uint64(ar.fileSize))
-		copy(b[8:], suffix)
+		var b []byte
+		if ar.opts.SignKey != nil {
+			b = ar.signedTrailer()
+		} else {
+			b = ar.unsignedTrailer()
+		}
 		_, err := ar.fw.Write(b)
 		ar.setErr(err)
 	}
@@ -104,8 +685,10 @@ func (ar *Archiver) Close() error {
 }
 
 type FileInfo struct {
-	Name string
-	Size int64
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
 }
 
 type File struct {
@@ -113,59 +696,409 @@ type File struct {
 	io.ReadCloser
 }
 
+// fileInfo is a plain fs.FileInfo built from a FormatHeader.
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi fileInfo) Sys() any           { return nil }
+
+// dirNode is a node in the synthetic directory tree built from the flat
+// list of entry names a FormatReader reports, since neither archive/zip
+// nor our tar+zstd index expose directories the way io/fs expects.
+type dirNode struct {
+	name     string
+	entry    *dirNodeEntry       // nil for synthetic directories
+	children map[string]*dirNode // nil for regular files
+}
+
+// dirNodeEntry pairs an entry's metadata with the FormatReader that can
+// open its contents.
+type dirNodeEntry struct {
+	header FormatHeader
+	reader FormatReader
+}
+
+func buildTree(fr FormatReader) *dirNode {
+	root := &dirNode{name: ".", children: map[string]*dirNode{}}
+	for _, h := range fr.Files() {
+		isDir := strings.HasSuffix(h.Name, "/")
+		name := strings.Trim(h.Name, "/")
+		if name == "" {
+			continue
+		}
+		parts := strings.Split(name, "/")
+		cur := root
+		for i, p := range parts {
+			if i == len(parts)-1 && !isDir {
+				cur.children[p] = &dirNode{name: p, entry: &dirNodeEntry{header: h, reader: fr}}
+				break
+			}
+			child, ok := cur.children[p]
+			if !ok || child.children == nil {
+				child = &dirNode{name: p, children: map[string]*dirNode{}}
+				cur.children[p] = child
+			}
+			cur = child
+		}
+	}
+	return root
+}
+
+func lookupNode(root *dirNode, name string) (*dirNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, fs.ErrInvalid
+	}
+	if name == "." {
+		return root, nil
+	}
+	cur := root
+	for _, p := range strings.Split(name, "/") {
+		if cur.children == nil {
+			return nil, fs.ErrNotExist
+		}
+		child, ok := cur.children[p]
+		if !ok {
+			return nil, fs.ErrNotExist
+		}
+		cur = child
+	}
+	return cur, nil
+}
+
+// dirInfo is the synthetic fs.FileInfo for a directory that has no
+// corresponding archive entry.
+type dirInfo struct{ name string }
+
+func (d dirInfo) Name() string       { return d.name }
+func (d dirInfo) Size() int64        { return 0 }
+func (d dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d dirInfo) ModTime() time.Time { return time.Time{} }
+func (d dirInfo) IsDir() bool        { return true }
+func (d dirInfo) Sys() any           { return nil }
+
+func nodeInfo(node *dirNode) fs.FileInfo {
+	if node.children != nil {
+		return dirInfo{name: node.name}
+	}
+	h := node.entry.header
+	return fileInfo{name: path.Base(h.Name), size: h.Size, mode: h.Mode, modTime: h.ModTime}
+}
+
+func nodeDirEntries(node *dirNode) []fs.DirEntry {
+	names := make([]string, 0, len(node.children))
+	for n := range node.children {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	entries := make([]fs.DirEntry, len(names))
+	for i, n := range names {
+		entries[i] = fs.FileInfoToDirEntry(nodeInfo(node.children[n]))
+	}
+	return entries
+}
+
+// dirFile implements fs.ReadDirFile for a synthetic directory. name is
+// the full path it was opened with, used for error reporting; base is
+// the directory's own base name, as fs.FileInfo.Name requires.
+type dirFile struct {
+	name    string
+	base    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return dirInfo{name: d.base}, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fmt.Errorf("is a directory")}
+}
+
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return entries, nil
+	}
+	if d.offset >= len(d.entries) {
+		return nil, io.EOF
+	}
+	end := d.offset + n
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	entries := d.entries[d.offset:end]
+	d.offset = end
+	return entries, nil
+}
+
+// entryFile wraps an archive entry's reader to satisfy fs.File.
+type entryFile struct {
+	io.ReadCloser
+	fi fs.FileInfo
+}
+
+func (f *entryFile) Stat() (fs.FileInfo, error) { return f.fi, nil }
+
+func openNode(root *dirNode, name string) (fs.File, error) {
+	node, err := lookupNode(root, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if node.children != nil {
+		return &dirFile{name: name, base: node.name, entries: nodeDirEntries(node)}, nil
+	}
+	rc, err := node.entry.reader.Open(node.entry.header.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &entryFile{ReadCloser: rc, fi: nodeInfo(node)}, nil
+}
+
+func statNode(root *dirNode, name string) (fs.FileInfo, error) {
+	node, err := lookupNode(root, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return nodeInfo(node), nil
+}
+
+func readDirNode(root *dirNode, name string) ([]fs.DirEntry, error) {
+	node, err := lookupNode(root, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	if node.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return nodeDirEntries(node), nil
+}
+
+func globNode(root *dirNode, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	var walk func(node *dirNode, full string)
+	walk = func(node *dirNode, full string) {
+		if ok, _ := path.Match(pattern, full); ok && full != "" {
+			matches = append(matches, full)
+		}
+		if node.children == nil {
+			return
+		}
+		names := make([]string, 0, len(node.children))
+		for n := range node.children {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		for _, n := range names {
+			child := n
+			if full != "" {
+				child = full + "/" + n
+			}
+			walk(node.children[n], child)
+		}
+	}
+	walk(root, "")
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// subFS implements fs.FS rooted at a sub-directory of a FileSystem, as
+// returned by FileSystem.Sub.
+type subFS struct{ root *dirNode }
+
+func (s *subFS) Open(name string) (fs.File, error)         { return openNode(s.root, name) }
+func (s *subFS) Stat(name string) (fs.FileInfo, error)      { return statNode(s.root, name) }
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) { return readDirNode(s.root, name) }
+func (s *subFS) Glob(pattern string) ([]string, error)      { return globNode(s.root, pattern) }
+
 type FileSystem struct {
 	BinarySize int64
-	f          *os.File
-	zr         *zip.Reader
-	files      map[string]*zip.File
-	mu         sync.RWMutex
+
+	// Signed, SignatureValid and SignerKey describe the trailer's Ed25519
+	// signature, if any: Signed reports whether a signed trailer is
+	// present, SignatureValid reports whether it verifies against its own
+	// embedded SignerKey. Callers that need to trust a specific signer
+	// should pass FileSystemOptions.VerifyKey instead of checking these
+	// directly.
+	Signed         bool
+	SignatureValid bool
+	SignerKey      ed25519.PublicKey
+
+	f     *os.File
+	fr    FormatReader
+	files map[string]FormatHeader
+	root  *dirNode
+	mu    sync.RWMutex
+}
+
+// FileSystemOptions configures NewFileSystemWithOptions.
+type FileSystemOptions struct {
+	// VerifyKey, if set, requires the archive to carry a signed trailer
+	// whose embedded public key matches VerifyKey and whose signature
+	// verifies against the actual payload bytes; NewFileSystemWithOptions
+	// fails otherwise.
+	VerifyKey ed25519.PublicKey
+
+	// ZstdDict is the shared dictionary to use when the archive's
+	// container format is TarZstdFormat.
+	ZstdDict []byte
 }
 
 func NewFileSystem(fn string) (*FileSystem, error) {
+	return NewFileSystemWithOptions(fn, FileSystemOptions{})
+}
+
+func NewFileSystemWithOptions(fn string, opts FileSystemOptions) (*FileSystem, error) {
 	f, err := os.Open(fn)
 	if err != nil {
 		return nil, err
 	}
-	start, end, err := readZipRegion(f)
+	tr, err := readTrailer(f)
 	if err != nil {
 		f.Close()
 		return nil, err
 	}
-	tr := &tailReader{
+
+	var sigValid bool
+	if tr.signed {
+		sigValid = verifyTrailer(f, tr) == nil
+	}
+	if opts.VerifyKey != nil {
+		if !tr.signed || !sigValid || !bytes.Equal(tr.pubkey, opts.VerifyKey) {
+			f.Close()
+			return nil, fmt.Errorf("file %s failed signature verification", fn)
+		}
+	}
+
+	format, err := formatFromByte(tr.format)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if tz, ok := format.(TarZstdFormat); ok && tz.Dict == nil && opts.ZstdDict != nil {
+		tz.Dict = opts.ZstdDict
+		format = tz
+	}
+
+	tailR := &tailReader{
 		r:   f,
-		off: start,
+		off: tr.start,
 	}
-	zr, err := zip.NewReader(tr, end-start)
+	fr, err := format.NewReader(tailR, tr.end-tr.start)
 	if err != nil {
 		f.Close()
 		return nil, err
 	}
-	files := make(map[string]*zip.File)
-	for _, zf := range zr.File {
-		files[zf.Name] = zf
+	files := make(map[string]FormatHeader)
+	for _, h := range fr.Files() {
+		files[h.Name] = h
 	}
 	return &FileSystem{
-		BinarySize: start,
-		f:          f,
-		zr:         zr,
-		files:      files,
+		BinarySize:     tr.start,
+		Signed:         tr.signed,
+		SignatureValid: sigValid,
+		SignerKey:      tr.pubkey,
+		f:              f,
+		fr:             fr,
+		files:          files,
+		root:           buildTree(fr),
 	}, nil
 }
 
-func readZipRegion(f *os.File) (start, end int64, err error) {
-	n := int64(8 + len(suffix))
-	if end, err = f.Seek(-n, 2); err != nil {
-		return 0, 0, err
+// archiveTrailer is the parsed trailer of a gar archive: the legacy
+// unsigned form (always zip), the unsigned form with a format byte, or
+// the signed form written when ArchiverOptions.SignKey is set.
+type archiveTrailer struct {
+	start, end int64 // payload region, as a byte range within the file
+	format     byte
+
+	signed bool
+	sig    []byte
+	hash   []byte
+	pubkey ed25519.PublicKey
+}
+
+func readTrailer(f *os.File) (*archiveTrailer, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
 	}
-	b := make([]byte, 8+len(suffix))
-	if _, err = io.ReadFull(f, b); err != nil {
-		return 0, 0, err
+
+	if size >= signedTrailerLen {
+		b := make([]byte, signedTrailerLen)
+		if _, err := f.ReadAt(b, size-signedTrailerLen); err == nil && bytes.HasSuffix(b, []byte(signedSuffix)) {
+			sig := append([]byte(nil), b[:ed25519.SignatureSize]...)
+			b = b[ed25519.SignatureSize:]
+			sum := append([]byte(nil), b[:sha256.Size]...)
+			b = b[sha256.Size:]
+			pub := ed25519.PublicKey(append([]byte(nil), b[:ed25519.PublicKeySize]...))
+			b = b[ed25519.PublicKeySize:]
+			start := int64(binary.BigEndian.Uint64(b[:8]))
+			format := b[8]
+			return &archiveTrailer{
+				start:  start,
+				end:    size - signedTrailerLen,
+				format: format,
+				signed: true,
+				sig:    sig,
+				hash:   sum,
+				pubkey: pub,
+			}, nil
+		}
+	}
+
+	if size >= unsignedTrailerLen {
+		b := make([]byte, unsignedTrailerLen)
+		if _, err := f.ReadAt(b, size-unsignedTrailerLen); err == nil && bytes.HasSuffix(b, []byte(suffix)) {
+			format := b[8]
+			if format == formatZip || format == formatTarZstd {
+				start := int64(binary.BigEndian.Uint64(b[:8]))
+				return &archiveTrailer{start: start, end: size - unsignedTrailerLen, format: format}, nil
+			}
+		}
+	}
+
+	if size < legacyTrailerLen {
+		return nil, fmt.Errorf("file %s too short to be a gar archive", f.Name())
+	}
+	b := make([]byte, legacyTrailerLen)
+	if _, err := f.ReadAt(b, size-legacyTrailerLen); err != nil {
+		return nil, err
 	}
 	if !bytes.HasSuffix(b, []byte(suffix)) {
-		return 0, 0, fmt.Errorf("file %s not end with %s", f.Name(), suffix)
+		return nil, fmt.Errorf("file %s not end with %s", f.Name(), suffix)
 	}
-	start = int64(binary.BigEndian.Uint64(b[:8]))
-	return start, end, nil
+	start := int64(binary.BigEndian.Uint64(b[:8]))
+	return &archiveTrailer{start: start, end: size - legacyTrailerLen, format: formatZip}, nil
+}
+
+// verifyTrailer re-hashes the payload region described by tr and checks
+// it against the hash and signature recorded in the trailer.
+func verifyTrailer(f *os.File, tr *archiveTrailer) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, tr.start, tr.end-tr.start)); err != nil {
+		return err
+	}
+	sum := h.Sum(nil)
+	if !bytes.Equal(sum, tr.hash) {
+		return fmt.Errorf("archive hash does not match trailer")
+	}
+	if !ed25519.Verify(tr.pubkey, sum, tr.sig) {
+		return fmt.Errorf("archive signature verification failed")
+	}
+	return nil
 }
 
 type tailReader struct {
@@ -177,49 +1110,116 @@ func (tr *tailReader) ReadAt(p []byte, off int64) (int, error) {
 	return tr.r.ReadAt(p, tr.off+off)
 }
 
-func (fs *FileSystem) Open(name string) (*File, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+// Open implements fs.FS. The returned fs.File is backed directly by the
+// embedded archive entry for files, or by a synthetic directory listing
+// for entries that only exist as path prefixes.
+func (fsys *FileSystem) Open(name string) (fs.File, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	if fsys.root == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrClosed}
+	}
+	return openNode(fsys.root, name)
+}
+
+// Stat implements fs.StatFS.
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	if fsys.root == nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrClosed}
+	}
+	return statNode(fsys.root, name)
+}
+
+// ReadDir implements fs.ReadDirFS.
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
 
-	zf, ok := fs.files[name]
+	if fsys.root == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrClosed}
+	}
+	return readDirNode(fsys.root, name)
+}
+
+// Glob implements fs.GlobFS.
+func (fsys *FileSystem) Glob(pattern string) ([]string, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	if fsys.root == nil {
+		return nil, &fs.PathError{Op: "glob", Path: pattern, Err: fs.ErrClosed}
+	}
+	return globNode(fsys.root, pattern)
+}
+
+// Sub implements fs.SubFS.
+func (fsys *FileSystem) Sub(dir string) (fs.FS, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	if fsys.root == nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrClosed}
+	}
+	node, err := lookupNode(fsys.root, dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: err}
+	}
+	if node.children == nil {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fmt.Errorf("not a directory")}
+	}
+	return &subFS{root: node}, nil
+}
+
+// OpenFile is the original Open, kept for callers that want the raw
+// io.ReadCloser and flat FileInfo instead of an fs.File. name is the
+// archive entry name, not a rooted io/fs path.
+func (fsys *FileSystem) OpenFile(name string) (*File, error) {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
+
+	h, ok := fsys.files[name]
 	if !ok {
 		return nil, fmt.Errorf("file %s not found", name)
 	}
-	rc, err := zf.Open()
+	rc, err := fsys.fr.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	return &File{
-		FileInfo: FileInfo{
-			Name: zf.Name,
-			Size: int64(zf.UncompressedSize64),
-		},
+		FileInfo:   fileInfoFromHeader(h),
 		ReadCloser: rc,
 	}, nil
 }
 
-func (fs *FileSystem) List() []*FileInfo {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+func fileInfoFromHeader(h FormatHeader) FileInfo {
+	return FileInfo{Name: h.Name, Size: h.Size, Mode: h.Mode, ModTime: h.ModTime}
+}
+
+func (fsys *FileSystem) List() []*FileInfo {
+	fsys.mu.RLock()
+	defer fsys.mu.RUnlock()
 
-	fns := make([]*FileInfo, 0, len(fs.files))
-	for _, zf := range fs.files {
-		fns = append(fns, &FileInfo{
-			Name: zf.Name,
-			Size: int64(zf.UncompressedSize64),
-		})
+	fns := make([]*FileInfo, 0, len(fsys.files))
+	for _, h := range fsys.files {
+		fi := fileInfoFromHeader(h)
+		fns = append(fns, &fi)
 	}
 	return fns
 }
 
-func (fs *FileSystem) Close() error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+func (fsys *FileSystem) Close() error {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
 
-	err := fs.f.Close()
-	fs.f = nil
-	fs.zr = nil
-	fs.files = nil
+	err := fsys.f.Close()
+	fsys.f = nil
+	fsys.fr = nil
+	fsys.files = nil
+	fsys.root = nil
 	return err
 }
 
@@ -230,19 +1230,29 @@ var (
 
 func initProgFS() {
 	fn := os.Args[0]
-	fs, err := NewFileSystem(fn)
+	fsys, err := NewFileSystem(fn)
 	if err != nil {
 		panic(fmt.Sprintf("failed to init gar file system from %s, err:%v", fn, err))
 	}
-	progFS = fs
+	progFS = fsys
 }
 
+// Open returns the named entry from the program's own embedded archive,
+// using the flat entry-name form. See FS for an io/fs.FS view.
 func Open(name string) (*File, error) {
 	once.Do(initProgFS)
-	return progFS.Open(name)
+	return progFS.OpenFile(name)
 }
 
 func List() []*FileInfo {
 	once.Do(initProgFS)
 	return progFS.List()
 }
+
+// FS returns the program's own embedded archive as an io/fs.FS, so it can
+// be passed directly to http.FileServer, template.ParseFS, fs.WalkDir,
+// etc.
+func FS() fs.FS {
+	once.Do(initProgFS)
+	return progFS
+}
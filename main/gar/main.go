@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/hex"
 	"flag"
 	"fmt"
 	"io"
@@ -16,12 +17,15 @@ func main() {
 	inspectOpt := flag.Bool("t", false, "inspect gar file")
 	trimOpt := flag.Bool("r", false, "trim gar file to restore the original binary")
 	extractOpt := flag.Bool("e", false, "extract files")
+	verifyOpt := flag.Bool("v", false, "report signer pubkey and signature verification status")
+	treeOpt := flag.Bool("R", false, "with -a, recursively archive directories")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `Usage:
   gar -t <go_binary>
-  gar -a <go_binary> <file>...
+  gar -a [-R] <go_binary> <file_or_dir>...
   gar -r <go_binary>
   gar -e <go_binary> <file>...
+  gar -v <go_binary>
 
 `)
 		flag.PrintDefaults()
@@ -34,7 +38,7 @@ func main() {
 		if flag.NArg() < 2 {
 			flag.Usage()
 		}
-		archive(flag.Arg(0), flag.Args()[1:])
+		archive(flag.Arg(0), flag.Args()[1:], *treeOpt)
 
 	case *inspectOpt:
 		if flag.NArg() != 1 {
@@ -54,11 +58,32 @@ func main() {
 		}
 		extract(flag.Arg(0), flag.Args()[1:])
 
+	case *verifyOpt:
+		if flag.NArg() != 1 {
+			flag.Usage()
+		}
+		verify(flag.Arg(0))
+
 	default:
 		flag.Usage()
 	}
 }
 
+func verify(fn string) {
+	fs, err := gar.NewFileSystem(fn)
+	if err != nil {
+		log.Fatalf("open file:%s err:%v", fn, err)
+	}
+	defer fs.Close()
+
+	if !fs.Signed {
+		fmt.Println("unsigned")
+		return
+	}
+	fmt.Printf("signer:   %s\n", hex.EncodeToString(fs.SignerKey))
+	fmt.Printf("verified: %v\n", fs.SignatureValid)
+}
+
 func inspect(fn string) {
 	fs, err := gar.NewFileSystem(fn)
 	if err != nil {
@@ -72,7 +97,7 @@ func inspect(fn string) {
 	}
 }
 
-func archive(binfn string, fns []string) {
+func archive(binfn string, fns []string, tree bool) {
 	ar, err := gar.NewArchiver(binfn)
 	if err != nil {
 		log.Fatalf("new archiver err:%v", err)
@@ -80,7 +105,12 @@ func archive(binfn string, fns []string) {
 	defer ar.Close()
 
 	for _, fn := range fns {
-		if err = ar.Add(fn); err != nil {
+		if tree {
+			err = ar.AddTree(fn, gar.TreeOptions{})
+		} else {
+			err = ar.Add(fn)
+		}
+		if err != nil {
 			log.Fatalf("add file:%s err:%v", fn, err)
 		}
 		log.Printf("file:%s added", fn)
@@ -115,7 +145,7 @@ func extract(binfn string, fns []string) {
 	}
 
 	for _, fn := range fns {
-		f, err := fs.Open(fn)
+		f, err := fs.OpenFile(fn)
 		if err != nil {
 			log.Printf("fail to extract file:%s err:%v", fn, err)
 			continue
@@ -134,5 +164,12 @@ func extract(binfn string, fns []string) {
 		}
 		f.Close()
 		out.Close()
+
+		if err := os.Chmod(fn, f.Mode); err != nil {
+			log.Printf("fail to chmod file:%s err:%v", fn, err)
+		}
+		if err := os.Chtimes(fn, f.ModTime, f.ModTime); err != nil {
+			log.Printf("fail to chtimes file:%s err:%v", fn, err)
+		}
 	}
 }